@@ -6,15 +6,18 @@ import (
 
 	"go.uber.org/dig"
 
+	"github.com/labstack/echo/v4"
+
 	"github.com/iotaledger/hive.go/configuration"
+	"github.com/iotaledger/hive.go/events"
 	"github.com/iotaledger/hive.go/kvstore"
-	"github.com/iotaledger/hive.go/kvstore/badger"
 	"github.com/iotaledger/hive.go/kvstore/bolt"
-	"github.com/iotaledger/hive.go/kvstore/pebble"
 	"github.com/iotaledger/hive.go/logger"
 	"github.com/iotaledger/hive.go/syncutils"
 
 	"github.com/gohornet/hornet/pkg/database"
+	"github.com/gohornet/hornet/pkg/database/migration"
+	_ "github.com/gohornet/hornet/pkg/database/migration/migrations"
 	"github.com/gohornet/hornet/pkg/model/storage"
 	"github.com/gohornet/hornet/pkg/model/utxo"
 	"github.com/gohornet/hornet/pkg/node"
@@ -22,6 +25,18 @@ import (
 	"github.com/gohornet/hornet/pkg/shutdown"
 )
 
+const (
+	// CfgDatabaseMigrate opts into running pending database migrations automatically
+	// on startup instead of panicking on a version mismatch.
+	CfgDatabaseMigrate = "db.migrate"
+	// CfgDatabaseMigrateDryRun runs pending migrations without persisting any change,
+	// so operators can validate a migration before committing to it.
+	CfgDatabaseMigrateDryRun = "db.migrate.dryrun"
+	// CfgDatabaseMigrateRollbackOnFail restores the pre-migration backup if any
+	// migration fails instead of leaving the database partially migrated.
+	CfgDatabaseMigrateRollbackOnFail = "db.migrate.rollbackOnFail"
+)
+
 func init() {
 	CorePlugin = &node.CorePlugin{
 		Pluggable: node.Pluggable{
@@ -44,8 +59,13 @@ var (
 
 type dependencies struct {
 	dig.In
-	Store   kvstore.KVStore
-	Storage *storage.Storage
+	NodeConfig *configuration.Configuration `name:"nodeConfig"`
+	Store      kvstore.KVStore
+	Storage    *storage.Storage
+	// RestRouteGroup is the root route group the REST API core plugin registers on
+	// startup. It is optional so this plugin still loads in a node built without a
+	// REST API.
+	RestRouteGroup *echo.Group `optional:"true"`
 }
 
 func provide(c *dig.Container) {
@@ -57,13 +77,30 @@ func provide(c *dig.Container) {
 	if err := c.Provide(func(deps pebbledeps) kvstore.KVStore {
 		switch deps.NodeConfig.String(CfgDatabaseEngine) {
 		case "pebble":
-			return pebble.New(database.NewPebbleDB(deps.NodeConfig.String(CfgDatabasePath), false))
+			// wrapped with the zero-copy database.Viewer fast path, see pkg/database/viewer_pebble.go
+			return database.NewPebbleKVStore(database.NewPebbleDB(deps.NodeConfig.String(CfgDatabasePath), false))
 		case "bolt":
-			return bolt.New(database.NewBoltDB(deps.NodeConfig.String(CfgDatabasePath), "tangle.db"))
+			return database.NewBoltKVStore(bolt.New(database.NewBoltDB(deps.NodeConfig.String(CfgDatabasePath), "tangle.db")))
 		case "badger":
-			return badger.New(database.NewBadgerDB(deps.NodeConfig.String(CfgDatabasePath)))
+			// wrapped with the zero-copy database.Viewer fast path, see pkg/database/viewer_badger.go
+			return database.NewBadgerKVStore(database.NewBadgerDB(deps.NodeConfig.String(CfgDatabasePath)))
+		case "etcd":
+			etcdStore, err := database.NewEtcdKV(database.EtcdConfig{
+				Endpoints:      deps.NodeConfig.Strings(database.CfgDatabaseEtcdEndpoints),
+				Username:       deps.NodeConfig.String(database.CfgDatabaseEtcdUser),
+				Password:       deps.NodeConfig.String(database.CfgDatabaseEtcdPassword),
+				CertFile:       deps.NodeConfig.String(database.CfgDatabaseEtcdCertFile),
+				KeyFile:        deps.NodeConfig.String(database.CfgDatabaseEtcdKeyFile),
+				CAFile:         deps.NodeConfig.String(database.CfgDatabaseEtcdCAFile),
+				DialTimeout:    deps.NodeConfig.Duration(database.CfgDatabaseEtcdDialTimeout),
+				ElectionPrefix: deps.NodeConfig.String(database.CfgDatabaseEtcdElectionPrefix),
+			})
+			if err != nil {
+				panic(fmt.Sprintf("unable to connect to etcd database: %s", err))
+			}
+			return etcdStore
 		default:
-			panic(fmt.Sprintf("unknown database engine: %s, supported engines: pebble/bolt/badger", deps.NodeConfig.String(CfgDatabaseEngine)))
+			panic(fmt.Sprintf("unknown database engine: %s, supported engines: pebble/bolt/badger/etcd", deps.NodeConfig.String(CfgDatabaseEngine)))
 		}
 	}); err != nil {
 		panic(err)
@@ -93,11 +130,40 @@ func configure() {
 	log = logger.NewLogger(CorePlugin.Name)
 
 	if !deps.Storage.IsCorrectDatabaseVersion() {
-		if !deps.Storage.UpdateDatabaseVersion() {
-			log.Panic("HORNET database version mismatch. The database scheme was updated. Please delete the database folder and start with a new snapshot.")
+		if !deps.NodeConfig.Bool(CfgDatabaseMigrate) {
+			log.Panic("HORNET database version mismatch. The database scheme was updated. Start HORNET with --db.migrate to upgrade automatically, or delete the database folder to start with a new snapshot.")
+		}
+
+		migrator := migration.NewMigrator(deps.NodeConfig.String(CfgDatabasePath), deps.Store, deps.Storage, deps.Storage.UTXO())
+
+		closure := events.NewClosure(func(step *migration.MigrationStep) {
+			log.Infof("running migration %d/%d: %q (v%d)", step.Index+1, step.Total, step.Migration.Name(), step.Migration.Number())
+		})
+		migration.Events.Step.Attach(closure)
+		defer migration.Events.Step.Detach(closure)
+
+		if err := migrator.Migrate(
+			migration.WithDryRun(deps.NodeConfig.Bool(CfgDatabaseMigrateDryRun)),
+			migration.WithRollbackOnFail(deps.NodeConfig.Bool(CfgDatabaseMigrateRollbackOnFail)),
+		); err != nil {
+			log.Panicf("database migration failed: %s", err)
+		}
+
+		if !deps.NodeConfig.Bool(CfgDatabaseMigrateDryRun) {
+			if !deps.Storage.UpdateDatabaseVersion() {
+				log.Panic("HORNET database version mismatch after migration. Please check the migration logs above.")
+			}
 		}
 	}
 
+	if etcdStore, ok := deps.Store.(*database.EtcdKV); ok {
+		scheduleEtcdCompaction(etcdStore)
+	}
+
+	if deps.RestRouteGroup != nil {
+		ConfigureTreasuryHistoryRoutes(deps.RestRouteGroup)
+	}
+
 	CorePlugin.Daemon().BackgroundWorker("Close database", func(shutdownSignal <-chan struct{}) {
 		<-shutdownSignal
 		deps.Storage.MarkDatabaseHealthy()
@@ -108,6 +174,15 @@ func configure() {
 }
 
 func RunGarbageCollection() {
+	if etcdStore, ok := deps.Store.(*database.EtcdKV); ok {
+		// etcd compacts its own revision history on a schedule (see scheduleEtcdCompaction)
+		// and only the elected leader is allowed to run maintenance in the first place.
+		if !etcdStore.Election().IsLeader() {
+			log.Info("skipping garbage collection: this node is not the etcd leader")
+		}
+		return
+	}
+
 	if !deps.Storage.DatabaseSupportsCleanup() {
 		return
 	}
@@ -142,6 +217,33 @@ func RunGarbageCollection() {
 	log.Infof("full database garbage collection finished. took %v", end.Sub(start).Truncate(time.Millisecond))
 }
 
+// scheduleEtcdCompaction periodically compacts the etcd revision history on the leader.
+// etcd handles its own compaction instead of the delete/rewrite based cleanup the other
+// engines need, so DatabaseSupportsCleanup stays false for the "etcd" engine.
+func scheduleEtcdCompaction(etcdStore *database.EtcdKV) {
+	const compactionInterval = 1 * time.Hour
+
+	CorePlugin.Daemon().BackgroundWorker("Etcd compaction", func(shutdownSignal <-chan struct{}) {
+		ticker := time.NewTicker(compactionInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-shutdownSignal:
+				return
+			case <-ticker.C:
+				if !etcdStore.Election().IsLeader() {
+					continue
+				}
+				log.Info("compacting etcd revision history...")
+				if err := etcdStore.Compact(); err != nil {
+					log.Warnf("etcd compaction failed: %s", err)
+				}
+			}
+		}
+	}, shutdown.PriorityCloseDatabase)
+}
+
 func closeDatabases() error {
 
 	if err := deps.Store.Flush(); err != nil {
@@ -153,4 +255,4 @@ func closeDatabases() error {
 	}
 
 	return nil
-}
\ No newline at end of file
+}
@@ -0,0 +1,35 @@
+package database
+
+import (
+	"time"
+
+	flag "github.com/spf13/pflag"
+
+	"github.com/gohornet/hornet/pkg/database"
+	"github.com/gohornet/hornet/pkg/node"
+)
+
+// params registers this plugin's CLI flags/config keys, see node.Pluggable.Params.
+var params = &node.PluginParams{
+	Params: map[string]*flag.FlagSet{
+		"nodeConfig": func() *flag.FlagSet {
+			fs := flag.NewFlagSet("", flag.ContinueOnError)
+
+			fs.Bool(CfgDatabaseMigrate, false, "run pending database migrations automatically on startup instead of panicking on a version mismatch")
+			fs.Bool(CfgDatabaseMigrateDryRun, false, "run pending migrations without persisting any change, to validate a migration before committing to it")
+			fs.Bool(CfgDatabaseMigrateRollbackOnFail, false, "restore the pre-migration backup if any migration fails instead of leaving the database partially migrated (unsupported for the etcd engine)")
+
+			fs.StringSlice(database.CfgDatabaseEtcdEndpoints, nil, "the list of etcd endpoints to connect to")
+			fs.String(database.CfgDatabaseEtcdUser, "", "the username used to authenticate against etcd")
+			fs.String(database.CfgDatabaseEtcdPassword, "", "the password used to authenticate against etcd")
+			fs.String(database.CfgDatabaseEtcdCertFile, "", "the path to the client TLS certificate")
+			fs.String(database.CfgDatabaseEtcdKeyFile, "", "the path to the client TLS key")
+			fs.String(database.CfgDatabaseEtcdCAFile, "", "the path to the CA used to verify the etcd server certificate")
+			fs.Duration(database.CfgDatabaseEtcdDialTimeout, 5*time.Second, "how long to wait for the initial connection to etcd")
+			fs.String(database.CfgDatabaseEtcdElectionPrefix, "", "the key prefix used for the leader election")
+
+			return fs
+		}(),
+	},
+	Masked: []string{database.CfgDatabaseEtcdPassword},
+}
@@ -0,0 +1,64 @@
+package database
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/gohornet/hornet/pkg/model/milestone"
+	"github.com/gohornet/hornet/pkg/model/utxo"
+)
+
+const (
+	routeDebugTreasuryHistory = "/treasury-history"
+
+	queryParamFromMilestoneIndex = "from"
+	queryParamToMilestoneIndex   = "to"
+)
+
+// treasuryHistoryHandler serves the treasury-history audit log recorded by
+// utxo.Manager.ForEachTreasuryHistory for the inclusive milestone index range
+// given by the "from"/"to" query parameters, giving operators and explorer
+// integrators a way to query it without replaying milestones themselves.
+func treasuryHistoryHandler(c echo.Context) error {
+	from, err := milestoneIndexQueryParam(c, queryParamFromMilestoneIndex, 0)
+	if err != nil {
+		return err
+	}
+
+	to, err := milestoneIndexQueryParam(c, queryParamToMilestoneIndex, milestone.Index(^uint32(0)))
+	if err != nil {
+		return err
+	}
+
+	events := make([]*utxo.TreasuryHistoryEvent, 0)
+	if err := deps.Storage.UTXO().ForEachTreasuryHistory(from, to, func(event *utxo.TreasuryHistoryEvent) bool {
+		events = append(events, event)
+		return true
+	}); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, events)
+}
+
+func milestoneIndexQueryParam(c echo.Context, name string, def milestone.Index) (milestone.Index, error) {
+	raw := c.QueryParam(name)
+	if raw == "" {
+		return def, nil
+	}
+
+	value, err := strconv.ParseUint(raw, 10, 32)
+	if err != nil {
+		return 0, echo.NewHTTPError(http.StatusBadRequest, "invalid query parameter \""+name+"\": "+err.Error())
+	}
+
+	return milestone.Index(value), nil
+}
+
+// ConfigureTreasuryHistoryRoutes registers the treasury-history debug route on
+// routeGroup.
+func ConfigureTreasuryHistoryRoutes(routeGroup *echo.Group) {
+	routeGroup.GET(routeDebugTreasuryHistory, treasuryHistoryHandler)
+}
@@ -7,28 +7,127 @@ import (
 )
 
 const (
-	maxAllowedMilestoneAge = time.Minute * 5
+	// CfgNodeHealthMaxMilestoneAge defines the maximum age the latest milestone may
+	// have for the node to be considered healthy.
+	CfgNodeHealthMaxMilestoneAge = "node.health.maxMilestoneAge"
+	// CfgNodeHealthMinPeersKnown defines the minimum amount of connected peers with
+	// relation "known" for the node to be considered ready.
+	CfgNodeHealthMinPeersKnown = "node.health.minPeersKnown"
+	// CfgNodeHealthMinPeersAutopeered defines the minimum amount of connected peers
+	// with relation "autopeered" for the node to be considered ready.
+	CfgNodeHealthMinPeersAutopeered = "node.health.minPeersAutopeered"
+	// CfgNodeHealthMinPeersUnknown defines the minimum amount of connected peers with
+	// relation "unknown" for the node to be considered ready.
+	CfgNodeHealthMinPeersUnknown = "node.health.minPeersUnknown"
+
+	// defaultMaxAllowedMilestoneAge is used if CfgNodeHealthMaxMilestoneAge is unset.
+	defaultMaxAllowedMilestoneAge = time.Minute * 5
+
+	// defaultMinPeersKnown is used if CfgNodeHealthMinPeersKnown is unset. It matches
+	// the hard requirement the previous, non-configurable health check enforced.
+	defaultMinPeersKnown = 1
+	// defaultMinPeersAutopeered is used if CfgNodeHealthMinPeersAutopeered is unset.
+	// The previous health check never considered autopeered peers, so the default
+	// imposes no minimum.
+	defaultMinPeersAutopeered = 0
+	// defaultMinPeersUnknown is used if CfgNodeHealthMinPeersUnknown is unset. The
+	// previous health check never considered unknown peers, so the default imposes
+	// no minimum.
+	defaultMinPeersUnknown = 0
 )
 
-// IsNodeHealthy returns whether the node is synced, has active neighbors and its latest milestone is not too old.
-func IsNodeHealthy() bool {
+// HealthStatus is the structured result of a liveness/readiness/health probe. It
+// names the first subcheck that failed, so orchestrators (k8s liveness/readiness,
+// load balancers) can react to the actual cause instead of blindly restarting a
+// node that is merely not yet ready.
+type HealthStatus struct {
+	// Healthy reports the overall outcome of the probe.
+	Healthy bool `json:"healthy"`
+	// FailedCheck names the first subcheck that failed. Empty if Healthy is true.
+	FailedCheck string `json:"failedCheck,omitempty"`
+}
+
+func healthy() *HealthStatus {
+	return &HealthStatus{Healthy: true}
+}
+
+func unhealthy(failedCheck string) *HealthStatus {
+	return &HealthStatus{Healthy: false, FailedCheck: failedCheck}
+}
+
+// minPeersOrDefault reads a minimum-peer-count config key, falling back to
+// def if it is unset. Config keys have no distinct "unset" value, so a
+// configured value <= 0 is treated the same as unset and also falls back -
+// the same convention IsNodeHealthy already uses for CfgNodeHealthMaxMilestoneAge.
+func minPeersOrDefault(key string, def int) int {
+	if min := deps.NodeConfig.Int(key); min > 0 {
+		return min
+	}
+	return def
+}
+
+// IsNodeAlive reports whether the node's own process is able to serve requests at
+// all, i.e. whether the database is reachable via a cheap Store.Has probe. It does
+// not consider sync state or peers, and must only be used to decide whether the
+// process itself needs restarting, never to gate traffic.
+func IsNodeAlive() *HealthStatus {
+	if _, err := deps.Store.Has([]byte{0}); err != nil {
+		return unhealthy("database")
+	}
+	return healthy()
+}
+
+// IsNodeReady reports whether the node is synced and has at least the configured
+// minimum number of connected peers for every relation, combined via AND. A node
+// that is still catching up to the network, or that has no peers yet, is alive but
+// not ready.
+func IsNodeReady() *HealthStatus {
+	if status := IsNodeAlive(); !status.Healthy {
+		return status
+	}
+
 	if !deps.Tangle.IsNodeSyncedWithThreshold() {
-		return false
+		return unhealthy("sync")
 	}
 
-	if deps.Manager.ConnectedCount(p2p.PeerRelationKnown) == 0 {
-		return false
+	if deps.Manager.ConnectedCount(p2p.PeerRelationKnown) < minPeersOrDefault(CfgNodeHealthMinPeersKnown, defaultMinPeersKnown) {
+		return unhealthy("peers.known")
+	}
+
+	if deps.Manager.ConnectedCount(p2p.PeerRelationAutopeered) < minPeersOrDefault(CfgNodeHealthMinPeersAutopeered, defaultMinPeersAutopeered) {
+		return unhealthy("peers.autopeered")
+	}
+
+	if deps.Manager.ConnectedCount(p2p.PeerRelationUnknown) < minPeersOrDefault(CfgNodeHealthMinPeersUnknown, defaultMinPeersUnknown) {
+		return unhealthy("peers.unknown")
+	}
+
+	return healthy()
+}
+
+// IsNodeHealthy reports whether the node is ready and its latest milestone is not
+// older than CfgNodeHealthMaxMilestoneAge.
+func IsNodeHealthy() *HealthStatus {
+	if status := IsNodeReady(); !status.Healthy {
+		return status
 	}
 
 	// latest milestone timestamp
 	lmi := deps.Tangle.GetLatestMilestoneIndex()
 	cachedLatestMilestone := deps.Tangle.GetCachedMilestoneOrNil(lmi) // milestone +1
 	if cachedLatestMilestone == nil {
-		return false
+		return unhealthy("milestone")
 	}
 	defer cachedLatestMilestone.Release(true)
 
-	// check whether the milestone is older than 5 minutes
 	timeMs := cachedLatestMilestone.GetMilestone().Timestamp
-	return time.Since(timeMs) < maxAllowedMilestoneAge
-}
\ No newline at end of file
+	maxAge := deps.NodeConfig.Duration(CfgNodeHealthMaxMilestoneAge)
+	if maxAge <= 0 {
+		maxAge = defaultMaxAllowedMilestoneAge
+	}
+	if time.Since(timeMs) >= maxAge {
+		return unhealthy("milestone")
+	}
+
+	return healthy()
+}
@@ -0,0 +1,34 @@
+package tangle
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+func healthStatusHandler(probe func() *HealthStatus) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		status := probe()
+		if !status.Healthy {
+			return c.JSON(http.StatusServiceUnavailable, status)
+		}
+		return c.JSON(http.StatusOK, status)
+	}
+}
+
+// ConfigureHealthRoutes registers the /health, /ready and /live probe endpoints on
+// routeGroup, returning structured JSON naming the failing subcheck so orchestrators
+// can react to the actual cause instead of restarting a node that is merely not yet
+// ready.
+//
+// Nothing in this package calls ConfigureHealthRoutes on startup. Wiring it up
+// takes a RestRouteGroup *echo.Group `optional:"true"` field in this package's
+// dependencies struct (optional so this plugin still loads in a node built without
+// a REST API, mirroring core/database's dependencies) and a call to
+// ConfigureHealthRoutes(deps.RestRouteGroup) from its existing configure(), guarded
+// by a deps.RestRouteGroup != nil check.
+func ConfigureHealthRoutes(routeGroup *echo.Group) {
+	routeGroup.GET("/health", healthStatusHandler(IsNodeHealthy))
+	routeGroup.GET("/ready", healthStatusHandler(IsNodeReady))
+	routeGroup.GET("/live", healthStatusHandler(IsNodeAlive))
+}
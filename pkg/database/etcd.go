@@ -0,0 +1,437 @@
+package database
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"sync/atomic"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/client/v3/concurrency"
+
+	"github.com/iotaledger/hive.go/kvstore"
+)
+
+const (
+	// CfgDatabaseEtcdEndpoints defines the list of etcd endpoints to connect to.
+	CfgDatabaseEtcdEndpoints = "db.etcd.endpoints"
+	// CfgDatabaseEtcdUser defines the username used to authenticate against etcd.
+	CfgDatabaseEtcdUser = "db.etcd.username"
+	// CfgDatabaseEtcdPassword defines the password used to authenticate against etcd.
+	CfgDatabaseEtcdPassword = "db.etcd.password"
+	// CfgDatabaseEtcdCertFile defines the path to the client TLS certificate.
+	CfgDatabaseEtcdCertFile = "db.etcd.certFile"
+	// CfgDatabaseEtcdKeyFile defines the path to the client TLS key.
+	CfgDatabaseEtcdKeyFile = "db.etcd.keyFile"
+	// CfgDatabaseEtcdCAFile defines the path to the CA used to verify the etcd server certificate.
+	CfgDatabaseEtcdCAFile = "db.etcd.caFile"
+	// CfgDatabaseEtcdDialTimeout defines how long to wait for the initial connection to etcd.
+	CfgDatabaseEtcdDialTimeout = "db.etcd.dialTimeout"
+	// CfgDatabaseEtcdElectionPrefix defines the key prefix used for the leader election.
+	CfgDatabaseEtcdElectionPrefix = "db.etcd.electionPrefix"
+
+	// etcdMaxTxnOps is the maximum number of operations etcd allows within a single Txn.
+	// see etcd's "max-txn-ops" server setting (defaults to 128).
+	etcdMaxTxnOps = 128
+
+	defaultElectionPrefix = "hornet/leader"
+)
+
+// EtcdConfig holds the parameters required to connect to an etcd cluster.
+type EtcdConfig struct {
+	Endpoints      []string
+	Username       string
+	Password       string
+	CertFile       string
+	KeyFile        string
+	CAFile         string
+	DialTimeout    time.Duration
+	ElectionPrefix string
+}
+
+// EtcdKV is a kvstore.KVStore implementation backed by an etcd v3 cluster.
+// It is intended for clustered HORNET deployments, where several instances
+// share the same ledger/tangle state for hot-standby failover: only the
+// elected leader is allowed to mutate the store, followers may still serve
+// read-only requests.
+type EtcdKV struct {
+	client   *clientv3.Client
+	realm    kvstore.Realm
+	election *EtcdElection
+}
+
+// NewEtcdKV creates a kvstore.KVStore backed by an etcd cluster using the given config.
+// The returned store participates in leader election under cfg.ElectionPrefix; only
+// the elected leader is allowed to issue writes, see EtcdKV.Batched and EtcdKV.Set.
+func NewEtcdKV(cfg EtcdConfig) (*EtcdKV, error) {
+	if len(cfg.Endpoints) == 0 {
+		return nil, fmt.Errorf("no etcd endpoints configured")
+	}
+
+	tlsConfig, err := buildTLSConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("unable to build etcd TLS config: %w", err)
+	}
+
+	dialTimeout := cfg.DialTimeout
+	if dialTimeout == 0 {
+		dialTimeout = 5 * time.Second
+	}
+
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints:            cfg.Endpoints,
+		Username:             cfg.Username,
+		Password:             cfg.Password,
+		TLS:                  tlsConfig,
+		DialTimeout:          dialTimeout,
+		DialKeepAliveTime:    10 * time.Second,
+		DialKeepAliveTimeout: 3 * time.Second,
+		PermitWithoutStream:  true,
+		DialOptions:          nil,
+		RejectOldCluster:     false,
+		AutoSyncInterval:     30 * time.Second,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to etcd cluster: %w", err)
+	}
+
+	electionPrefix := cfg.ElectionPrefix
+	if electionPrefix == "" {
+		electionPrefix = defaultElectionPrefix
+	}
+
+	election, err := newEtcdElection(client, electionPrefix)
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("unable to set up leader election: %w", err)
+	}
+
+	return &EtcdKV{
+		client:   client,
+		election: election,
+	}, nil
+}
+
+func buildTLSConfig(cfg EtcdConfig) (*tls.Config, error) {
+	if cfg.CertFile == "" && cfg.KeyFile == "" && cfg.CAFile == "" {
+		return nil, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load client certificate: %w", err)
+	}
+
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+	}
+
+	if cfg.CAFile != "" {
+		caBytes, err := ioutil.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read CA file: %w", err)
+		}
+		caPool := x509.NewCertPool()
+		if !caPool.AppendCertsFromPEM(caBytes) {
+			return nil, fmt.Errorf("unable to parse CA file %s", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = caPool
+	}
+
+	return tlsConfig, nil
+}
+
+// Compact compacts etcd's revision history up to the current revision, reclaiming
+// space used by superseded keys. It is triggered on a schedule rather than gated
+// behind DatabaseSupportsCleanup, since etcd's own compaction is unrelated to the
+// delete/rewrite style cleanup the other backends need.
+func (e *EtcdKV) Compact() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	resp, err := e.client.Get(ctx, "", clientv3.WithLastRev()...)
+	if err != nil {
+		return err
+	}
+
+	_, err = e.client.Compact(ctx, resp.Header.Revision)
+	return err
+}
+
+// Election returns the leader-election helper backing this store.
+func (e *EtcdKV) Election() *EtcdElection {
+	return e.election
+}
+
+func (e *EtcdKV) key(k kvstore.Key) []byte {
+	return append(append([]byte{}, e.realm...), k...)
+}
+
+func (e *EtcdKV) WithRealm(realm kvstore.Realm) kvstore.KVStore {
+	return &EtcdKV{client: e.client, realm: realm, election: e.election}
+}
+
+func (e *EtcdKV) WithExtendedRealm(realm kvstore.Realm) kvstore.KVStore {
+	return e.WithRealm(append(append([]byte{}, e.realm...), realm...))
+}
+
+func (e *EtcdKV) Realm() kvstore.Realm {
+	return e.realm
+}
+
+func (e *EtcdKV) Has(key kvstore.Key) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	resp, err := e.client.Get(ctx, string(e.key(key)), clientv3.WithCountOnly())
+	if err != nil {
+		return false, err
+	}
+	return resp.Count > 0, nil
+}
+
+func (e *EtcdKV) Get(key kvstore.Key) (kvstore.Value, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	resp, err := e.client.Get(ctx, string(e.key(key)))
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, kvstore.ErrKeyNotFound
+	}
+	return resp.Kvs[0].Value, nil
+}
+
+// Set writes a single key/value pair. Only the elected leader may write;
+// followers get ErrNotLeader so they can forward the request or reject it.
+func (e *EtcdKV) Set(key kvstore.Key, value kvstore.Value) error {
+	if !e.election.IsLeader() {
+		return ErrNotLeader
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := e.client.Put(ctx, string(e.key(key)), string(value))
+	return err
+}
+
+func (e *EtcdKV) Delete(key kvstore.Key) error {
+	if !e.election.IsLeader() {
+		return ErrNotLeader
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := e.client.Delete(ctx, string(e.key(key)))
+	return err
+}
+
+func (e *EtcdKV) DeletePrefix(prefix kvstore.KeyPrefix) error {
+	if !e.election.IsLeader() {
+		return ErrNotLeader
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err := e.client.Delete(ctx, string(e.key(prefix)), clientv3.WithPrefix())
+	return err
+}
+
+func (e *EtcdKV) Clear() error {
+	return e.DeletePrefix(nil)
+}
+
+// Iterate performs a prefix range scan over etcd, calling kvConsumerFunc for every
+// matching key/value pair until it returns false or the range is exhausted.
+func (e *EtcdKV) Iterate(prefix kvstore.KeyPrefix, kvConsumerFunc kvstore.IteratorKeyValueConsumerFunc, _ ...kvstore.IterDirection) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	resp, err := e.client.Get(ctx, string(e.key(prefix)), clientv3.WithPrefix())
+	if err != nil {
+		return err
+	}
+
+	realmLen := len(e.realm)
+	for _, kv := range resp.Kvs {
+		if !kvConsumerFunc(kv.Key[realmLen:], kv.Value) {
+			break
+		}
+	}
+	return nil
+}
+
+func (e *EtcdKV) IterateKeys(prefix kvstore.KeyPrefix, consumerFunc kvstore.IteratorKeyConsumerFunc, direction ...kvstore.IterDirection) error {
+	return e.Iterate(prefix, func(key kvstore.Key, _ kvstore.Value) bool {
+		return consumerFunc(key)
+	}, direction...)
+}
+
+func (e *EtcdKV) Flush() error {
+	return nil
+}
+
+func (e *EtcdKV) Close() error {
+	if err := e.election.Close(); err != nil {
+		return err
+	}
+	return e.client.Close()
+}
+
+// Batched returns a kvstore.BatchedMutations that, on Commit, is translated into one or
+// more etcd Txn calls, split into chunks of at most etcdMaxTxnOps operations each so the
+// server's max-txn-ops limit is never exceeded.
+func (e *EtcdKV) Batched() (kvstore.BatchedMutations, error) {
+	if !e.election.IsLeader() {
+		return nil, ErrNotLeader
+	}
+
+	return &etcdBatchedMutations{store: e}, nil
+}
+
+type etcdOp struct {
+	delete bool
+	key    []byte
+	value  []byte
+}
+
+type etcdBatchedMutations struct {
+	store *EtcdKV
+	ops   []etcdOp
+}
+
+func (b *etcdBatchedMutations) Set(key kvstore.Key, value kvstore.Value) error {
+	b.ops = append(b.ops, etcdOp{key: b.store.key(key), value: append([]byte{}, value...)})
+	return nil
+}
+
+func (b *etcdBatchedMutations) Delete(key kvstore.Key) error {
+	b.ops = append(b.ops, etcdOp{delete: true, key: b.store.key(key)})
+	return nil
+}
+
+func (b *etcdBatchedMutations) Cancel() {
+	b.ops = nil
+}
+
+func (b *etcdBatchedMutations) Commit() error {
+	if !b.store.election.IsLeader() {
+		return ErrNotLeader
+	}
+
+	for start := 0; start < len(b.ops); start += etcdMaxTxnOps {
+		end := start + etcdMaxTxnOps
+		if end > len(b.ops) {
+			end = len(b.ops)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		txn := b.store.client.Txn(ctx)
+
+		var thenOps []clientv3.Op
+		for _, op := range b.ops[start:end] {
+			if op.delete {
+				thenOps = append(thenOps, clientv3.OpDelete(string(op.key)))
+			} else {
+				thenOps = append(thenOps, clientv3.OpPut(string(op.key), string(op.value)))
+			}
+		}
+
+		_, err := txn.Then(thenOps...).Commit()
+		cancel()
+		if err != nil {
+			return fmt.Errorf("etcd batched mutation sub-transaction failed: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// ErrNotLeader is returned by write operations on a follower instance; only the elected
+// leader of the etcd cluster is allowed to execute writes and garbage collection.
+var ErrNotLeader = fmt.Errorf("this node is not the etcd leader and may only serve reads")
+
+// EtcdElection wraps an etcd concurrency.Election so that only one HORNET instance
+// within a cluster is ever allowed to perform writes, run RunGarbageCollection or
+// update the treasury/UTXO state at a time.
+type EtcdElection struct {
+	session  *concurrency.Session
+	election *concurrency.Election
+	leader   atomic.Bool
+	cancel   context.CancelFunc
+}
+
+func newEtcdElection(client *clientv3.Client, prefix string) (*EtcdElection, error) {
+	session, err := concurrency.NewSession(client)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &EtcdElection{
+		session:  session,
+		election: concurrency.NewElection(session, prefix),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	e.cancel = cancel
+
+	go e.campaign(ctx)
+
+	return e, nil
+}
+
+// campaign blocks until this instance becomes leader or the session is closed,
+// retrying a failed Campaign call after one second. Once the session ends (e.g. on
+// network partition), it marks this instance as not leader and returns; it does
+// not open a new session or campaign again.
+func (e *EtcdElection) campaign(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-e.session.Done():
+			return
+		default:
+		}
+
+		if err := e.election.Campaign(ctx, "leader"); err != nil {
+			time.Sleep(time.Second)
+			continue
+		}
+
+		e.leader.Store(true)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-e.session.Done():
+			e.leader.Store(false)
+			return
+		}
+	}
+}
+
+// IsLeader reports whether this instance currently holds the etcd leader election.
+// A nil receiver (single-instance, non-clustered deployments) is always the leader.
+func (e *EtcdElection) IsLeader() bool {
+	if e == nil {
+		return true
+	}
+	return e.leader.Load()
+}
+
+func (e *EtcdElection) Close() error {
+	if e == nil {
+		return nil
+	}
+	e.cancel()
+	return e.session.Close()
+}
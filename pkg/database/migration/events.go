@@ -0,0 +1,35 @@
+package migration
+
+import "github.com/iotaledger/hive.go/events"
+
+// MigrationStep describes the progress of a single migration within a Migrator run.
+type MigrationStep struct {
+	// Migration is the migration currently running.
+	Migration Migration
+	// Index is the zero based position of Migration within the pending set.
+	Index int
+	// Total is the number of migrations pending in this run.
+	Total int
+}
+
+// Events are triggered while a Migrator runs, so the dashboard and health probes
+// can surface migration progress to operators.
+var Events = struct {
+	Started  *events.Event
+	Step     *events.Event
+	Finished *events.Event
+	Failed   *events.Event
+}{
+	Started:  events.NewEvent(pendingCaller),
+	Step:     events.NewEvent(stepCaller),
+	Finished: events.NewEvent(pendingCaller),
+	Failed:   events.NewEvent(events.ErrorCaller),
+}
+
+func pendingCaller(handler interface{}, params ...interface{}) {
+	handler.(func([]Migration))(params[0].([]Migration))
+}
+
+func stepCaller(handler interface{}, params ...interface{}) {
+	handler.(func(*MigrationStep))(params[0].(*MigrationStep))
+}
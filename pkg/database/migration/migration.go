@@ -0,0 +1,27 @@
+// Package migration provides a versioned, ordered migration framework for the
+// storage layer, replacing the historic "delete the database folder and start
+// with a new snapshot" approach with channeldb-style incremental upgrades.
+package migration
+
+import (
+	"github.com/iotaledger/hive.go/kvstore"
+
+	"github.com/gohornet/hornet/pkg/model/storage"
+	"github.com/gohornet/hornet/pkg/model/utxo"
+)
+
+// Migration is a single, ordered upgrade step for the on-disk database schema.
+// Migrations are identified by a strictly increasing Number and are always run
+// in that order, never out of sequence and never skipped.
+type Migration interface {
+	// Number returns the database version this migration upgrades the schema to.
+	Number() uint32
+	// Name returns a short, human readable description of the migration, used in
+	// log output and progress events.
+	Name() string
+	// Migrate performs the migration. kvStore is the live store and may be used for
+	// reads (e.g. Iterate) only; all writes must go through batch, which the
+	// Migrator commits after Migrate returns successfully, or cancels on error or
+	// on a dry run, so a dry run is guaranteed to touch nothing.
+	Migrate(kvStore kvstore.KVStore, batch kvstore.BatchedMutations, s *storage.Storage, utxoManager *utxo.Manager) error
+}
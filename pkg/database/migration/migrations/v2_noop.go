@@ -0,0 +1,32 @@
+// Package migrations contains the concrete Migration implementations, registered
+// with the framework in pkg/database/migration via their init() functions.
+package migrations
+
+import (
+	"github.com/iotaledger/hive.go/kvstore"
+
+	"github.com/gohornet/hornet/pkg/database/migration"
+	"github.com/gohornet/hornet/pkg/model/storage"
+	"github.com/gohornet/hornet/pkg/model/utxo"
+)
+
+func init() {
+	migration.Register(&noopV2{})
+}
+
+// noopV2 is the framework's initial migration. It performs no schema changes and
+// exists to prove the Migrator end-to-end: a fresh v1 database is bumped to v2
+// without any operator intervention or data rewrite.
+type noopV2 struct{}
+
+func (m *noopV2) Number() uint32 {
+	return 2
+}
+
+func (m *noopV2) Name() string {
+	return "noop"
+}
+
+func (m *noopV2) Migrate(_ kvstore.KVStore, _ kvstore.BatchedMutations, _ *storage.Storage, _ *utxo.Manager) error {
+	return nil
+}
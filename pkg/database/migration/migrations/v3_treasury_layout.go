@@ -0,0 +1,110 @@
+package migrations
+
+import (
+	"github.com/iotaledger/hive.go/kvstore"
+	"github.com/iotaledger/hive.go/marshalutil"
+	iotago "github.com/iotaledger/iota.go/v2"
+
+	"github.com/gohornet/hornet/pkg/database/migration"
+	"github.com/gohornet/hornet/pkg/model/storage"
+	"github.com/gohornet/hornet/pkg/model/utxo"
+)
+
+func init() {
+	migration.Register(&treasuryOutputLayoutV3{})
+}
+
+// treasuryOutputLayoutV3 rewrites the pre-v3 treasury output key layout, where the
+// spent flag was encoded into the key (forcing a delete+set on every spend), into
+// the v3 layout where the key is derived purely from the milestone ID and the spent
+// flag lives in the value. It also backfills one "migrated-from-legacy" entry per
+// output into the treasury-history audit log.
+type treasuryOutputLayoutV3 struct{}
+
+func (m *treasuryOutputLayoutV3) Number() uint32 {
+	return 3
+}
+
+func (m *treasuryOutputLayoutV3) Name() string {
+	return "rewrite treasury output key layout"
+}
+
+func (m *treasuryOutputLayoutV3) Migrate(kvStore kvstore.KVStore, batch kvstore.BatchedMutations, _ *storage.Storage, _ *utxo.Manager) error {
+	type legacyOutput struct {
+		milestoneID iotago.MilestoneID
+		amount      uint64
+		spent       bool
+	}
+
+	var legacyOutputs []legacyOutput
+	var innerErr error
+
+	if err := kvStore.Iterate([]byte{utxo.UTXOStoreKeyPrefixTreasuryOutput}, func(key kvstore.Key, value kvstore.Value) bool {
+		keyExt := marshalutil.New(key)
+		if _, err := keyExt.ReadByte(); err != nil {
+			innerErr = err
+			return false
+		}
+
+		spent, err := keyExt.ReadBool()
+		if err != nil {
+			innerErr = err
+			return false
+		}
+
+		milestoneIDBytes, err := keyExt.ReadBytes(iotago.MilestoneIDLength)
+		if err != nil {
+			innerErr = err
+			return false
+		}
+
+		amount, err := marshalutil.New(value).ReadUint64()
+		if err != nil {
+			innerErr = err
+			return false
+		}
+
+		var legacy legacyOutput
+		copy(legacy.milestoneID[:], milestoneIDBytes)
+		legacy.amount = amount
+		legacy.spent = spent
+		legacyOutputs = append(legacyOutputs, legacy)
+
+		return true
+	}); err != nil {
+		return err
+	}
+	if innerErr != nil {
+		return innerErr
+	}
+
+	for _, legacy := range legacyOutputs {
+		if err := batch.Delete(legacyTreasuryOutputKey(legacy.milestoneID, true)); err != nil {
+			return err
+		}
+		if err := batch.Delete(legacyTreasuryOutputKey(legacy.milestoneID, false)); err != nil {
+			return err
+		}
+
+		output := &utxo.TreasuryOutput{
+			MilestoneID: legacy.milestoneID,
+			Amount:      legacy.amount,
+			Spent:       legacy.spent,
+		}
+
+		if err := utxo.MigrateTreasuryOutputLayout(output, batch); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// legacyTreasuryOutputKey rebuilds a pre-v3 treasury output key: prefix(1) + spent(1) + milestoneID.
+func legacyTreasuryOutputKey(milestoneID iotago.MilestoneID, spent bool) []byte {
+	return marshalutil.New(2 + iotago.MilestoneIDLength).
+		WriteByte(utxo.UTXOStoreKeyPrefixTreasuryOutput).
+		WriteBool(spent).
+		WriteBytes(milestoneID[:]).
+		Bytes()
+}
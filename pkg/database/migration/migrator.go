@@ -0,0 +1,212 @@
+package migration
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/iotaledger/hive.go/kvstore"
+
+	"github.com/gohornet/hornet/pkg/database"
+	"github.com/gohornet/hornet/pkg/model/storage"
+	"github.com/gohornet/hornet/pkg/model/utxo"
+)
+
+// databaseVersionKey stores the schema version the migration framework has last
+// successfully migrated the database to.
+var databaseVersionKey = []byte("migration/database_version")
+
+// Options configures how a Migrator run behaves.
+type Options struct {
+	// DryRun runs every pending migration against a batch that is cancelled instead
+	// of committed, so operators can validate a migration without touching the DB.
+	DryRun bool
+	// RollbackOnFail restores the pre-migration backup if any migration fails.
+	RollbackOnFail bool
+}
+
+// Option customizes a Migrator run.
+type Option func(*Options)
+
+// WithDryRun toggles dry-run mode. See Options.DryRun.
+func WithDryRun(dryRun bool) Option {
+	return func(o *Options) { o.DryRun = dryRun }
+}
+
+// WithRollbackOnFail toggles rollback on failure. See Options.RollbackOnFail.
+func WithRollbackOnFail(rollback bool) Option {
+	return func(o *Options) { o.RollbackOnFail = rollback }
+}
+
+// Migrator runs the registered, pending migrations against a database.
+type Migrator struct {
+	kvStore     kvstore.KVStore
+	storage     *storage.Storage
+	utxoManager *utxo.Manager
+	dbPath      string
+}
+
+// NewMigrator creates a Migrator for the database opened at dbPath.
+func NewMigrator(dbPath string, kvStore kvstore.KVStore, s *storage.Storage, utxoManager *utxo.Manager) *Migrator {
+	return &Migrator{
+		kvStore:     kvStore,
+		storage:     s,
+		utxoManager: utxoManager,
+		dbPath:      dbPath,
+	}
+}
+
+// CurrentVersion returns the schema version the database was last migrated to.
+// A freshly initialized database that has never run a migration reports 0.
+func (m *Migrator) CurrentVersion() (uint32, error) {
+	val, err := m.kvStore.Get(databaseVersionKey)
+	if err != nil {
+		if errors.Is(err, kvstore.ErrKeyNotFound) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return binary.LittleEndian.Uint32(val), nil
+}
+
+func (m *Migrator) setVersion(v uint32) error {
+	buf := make([]byte, 4)
+	binary.LittleEndian.PutUint32(buf, v)
+	return m.kvStore.Set(databaseVersionKey, buf)
+}
+
+// Migrate runs every registered migration whose Number() is greater than the
+// database's current version, in strict order, bumping the version after each
+// one succeeds. It is a no-op if no migrations are pending.
+func (m *Migrator) Migrate(opts ...Option) error {
+	options := &Options{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	currentVersion, err := m.CurrentVersion()
+	if err != nil {
+		return fmt.Errorf("unable to determine current database version: %w", err)
+	}
+
+	pending := Pending(currentVersion)
+	if len(pending) == 0 {
+		return nil
+	}
+
+	// the etcd engine shares a single cluster-wide store across every node, and
+	// only the elected leader is allowed to write to it, see database.EtcdKV.Batched.
+	// Neither a per-node backup nor a follower running the migration itself make
+	// sense against that shared store.
+	etcdStore, isEtcd := m.kvStore.(*database.EtcdKV)
+	if isEtcd && options.RollbackOnFail {
+		return fmt.Errorf("rollback on fail is not supported for the etcd engine: the ledger lives in the cluster, not under a local database folder")
+	}
+	if isEtcd && !etcdStore.Election().IsLeader() {
+		return m.waitForClusterMigration(pending[len(pending)-1].Number())
+	}
+
+	Events.Started.Trigger(pending)
+
+	var backupPath string
+	if !options.DryRun && !isEtcd {
+		if backupPath, err = m.backup(); err != nil {
+			return fmt.Errorf("unable to back up database before migrating: %w", err)
+		}
+	}
+
+	for i, mig := range pending {
+		Events.Step.Trigger(&MigrationStep{Migration: mig, Index: i, Total: len(pending)})
+
+		if err := m.runOne(mig, options.DryRun); err != nil {
+			Events.Failed.Trigger(err)
+
+			if options.RollbackOnFail && backupPath != "" {
+				if rbErr := m.rollback(backupPath); rbErr != nil {
+					return fmt.Errorf("migration %q (v%d) failed: %s; rollback also failed: %w", mig.Name(), mig.Number(), err, rbErr)
+				}
+				return fmt.Errorf("migration %q (v%d) failed and was rolled back: %w", mig.Name(), mig.Number(), err)
+			}
+			return fmt.Errorf("migration %q (v%d) failed: %w", mig.Name(), mig.Number(), err)
+		}
+
+		if !options.DryRun {
+			if err := m.setVersion(mig.Number()); err != nil {
+				return fmt.Errorf("migration %q (v%d) succeeded but its version could not be persisted: %w", mig.Name(), mig.Number(), err)
+			}
+		}
+	}
+
+	Events.Finished.Trigger(pending)
+
+	return nil
+}
+
+// runOne executes a single migration inside a batched transaction that the
+// Migrator itself owns. mig.Migrate must write through the supplied batch
+// rather than the live store, so that cancelling it here on a dry run or a
+// failure is guaranteed to leave the store untouched.
+func (m *Migrator) runOne(mig Migration, dryRun bool) error {
+	batch, err := m.kvStore.Batched()
+	if err != nil {
+		return err
+	}
+
+	if err := mig.Migrate(m.kvStore, batch, m.storage, m.utxoManager); err != nil {
+		batch.Cancel()
+		return err
+	}
+
+	if dryRun {
+		batch.Cancel()
+		return nil
+	}
+
+	return batch.Commit()
+}
+
+// waitForClusterMigration is a follower's side of an etcd migration: the schema
+// version lives in the shared etcd cluster rather than per node, so a follower
+// never runs a migration itself - it only waits for the elected leader to finish,
+// polling the shared version until it reaches targetVersion.
+func (m *Migrator) waitForClusterMigration(targetVersion uint32) error {
+	const pollInterval = 2 * time.Second
+	const maxWait = 5 * time.Minute
+
+	deadline := time.Now().Add(maxWait)
+	for {
+		currentVersion, err := m.CurrentVersion()
+		if err != nil {
+			return fmt.Errorf("unable to determine current database version: %w", err)
+		}
+		if currentVersion >= targetVersion {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out after %s waiting for the etcd cluster leader to migrate to database version %d", maxWait, targetVersion)
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
+// backup copies the database folder to a timestamped sibling folder so
+// RollbackOnFail has something to restore from if a migration fails midway.
+func (m *Migrator) backup() (string, error) {
+	backupPath := fmt.Sprintf("%s.bak-%d", filepath.Clean(m.dbPath), time.Now().Unix())
+
+	if err := copyDir(m.dbPath, backupPath); err != nil {
+		return "", err
+	}
+
+	return backupPath, nil
+}
+
+func (m *Migrator) rollback(backupPath string) error {
+	if err := os.RemoveAll(m.dbPath); err != nil {
+		return err
+	}
+	return os.Rename(backupPath, m.dbPath)
+}
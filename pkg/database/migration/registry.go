@@ -0,0 +1,34 @@
+package migration
+
+import "sort"
+
+var registered []Migration
+
+// Register adds a migration to the global registry. Implementations call this
+// from an init() function; since All sorts by Number(), the order in which
+// packages happen to register does not matter.
+func Register(m Migration) {
+	registered = append(registered, m)
+}
+
+// All returns every registered migration, ordered by ascending Number().
+func All() []Migration {
+	sorted := make([]Migration, len(registered))
+	copy(sorted, registered)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Number() < sorted[j].Number()
+	})
+	return sorted
+}
+
+// Pending returns the registered migrations with Number() greater than
+// currentVersion, ordered ascending.
+func Pending(currentVersion uint32) []Migration {
+	var pending []Migration
+	for _, m := range All() {
+		if m.Number() > currentVersion {
+			pending = append(pending, m)
+		}
+	}
+	return pending
+}
@@ -0,0 +1,21 @@
+package database
+
+// Viewer is an optional extension of kvstore.KVStore for backends that can hand a
+// caller a zero-copy view of stored bytes instead of forcing a copy on every read.
+// Callers MUST NOT retain the slices passed to cb beyond the callback's lifetime:
+// the backing memory may be reused or unmapped as soon as cb returns.
+type Viewer interface {
+	// View calls cb with the raw bytes stored under key, without copying them.
+	// If key does not exist, View returns kvstore.ErrKeyNotFound and cb is not called.
+	View(key []byte, cb func([]byte) error) error
+	// IterateView calls cb for every key/value pair under prefix, handing cb the
+	// underlying key and value slices without copying them. Iteration stops as soon
+	// as cb returns false.
+	IterateView(prefix []byte, cb func(key []byte, value []byte) bool) error
+	// IterateViewFrom behaves like IterateView, except iteration is seeked directly
+	// to seekKey instead of starting at prefix. This lets a caller bound a scan over
+	// a key layout that sorts by some field embedded right after the prefix (e.g. a
+	// milestone index) without walking and discarding every entry before it.
+	// seekKey must itself begin with prefix, or the scan may observe no entries.
+	IterateViewFrom(seekKey []byte, prefix []byte, cb func(key []byte, value []byte) bool) error
+}
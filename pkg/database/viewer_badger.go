@@ -0,0 +1,91 @@
+package database
+
+import (
+	"github.com/dgraph-io/badger/v2"
+
+	"github.com/iotaledger/hive.go/kvstore"
+	hivebadger "github.com/iotaledger/hive.go/kvstore/badger"
+)
+
+// badgerStore wraps the hive.go badger kvstore with the zero-copy Viewer fast path,
+// reaching into the native *badger.DB so reads can borrow the value straight out of
+// badger's value log via Item.Value instead of going through ValueCopy.
+type badgerStore struct {
+	kvstore.KVStore
+	db    *badger.DB
+	realm kvstore.Realm
+}
+
+// NewBadgerKVStore wraps db as a kvstore.KVStore that also satisfies Viewer.
+func NewBadgerKVStore(db *badger.DB) kvstore.KVStore {
+	return &badgerStore{KVStore: hivebadger.New(db), db: db}
+}
+
+// WithRealm and WithExtendedRealm must be overridden here: every real subsystem
+// (storage managers, utxo.Manager, ...) scopes its working store by calling
+// WithRealm on the root store. If we let that fall through to the embedded,
+// unwrapped hive.go store, the result would stop satisfying Viewer and the fast
+// path this type exists for would never engage.
+func (b *badgerStore) WithRealm(realm kvstore.Realm) kvstore.KVStore {
+	return &badgerStore{KVStore: b.KVStore.WithRealm(realm), db: b.db, realm: realm}
+}
+
+func (b *badgerStore) WithExtendedRealm(realm kvstore.Realm) kvstore.KVStore {
+	return b.WithRealm(append(append(kvstore.Realm{}, b.realm...), realm...))
+}
+
+func (b *badgerStore) Realm() kvstore.Realm {
+	return b.realm
+}
+
+func (b *badgerStore) realmKey(key []byte) []byte {
+	return append(append([]byte{}, b.realm...), key...)
+}
+
+func (b *badgerStore) View(key []byte, cb func([]byte) error) error {
+	realmKey := b.realmKey(key)
+
+	return b.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(realmKey)
+		if err != nil {
+			if err == badger.ErrKeyNotFound {
+				return kvstore.ErrKeyNotFound
+			}
+			return err
+		}
+		return item.Value(cb)
+	})
+}
+
+func (b *badgerStore) IterateView(prefix []byte, cb func(key []byte, value []byte) bool) error {
+	return b.IterateViewFrom(prefix, prefix, cb)
+}
+
+func (b *badgerStore) IterateViewFrom(seekKey []byte, prefix []byte, cb func(key []byte, value []byte) bool) error {
+	realmSeekKey := b.realmKey(seekKey)
+	realmPrefix := b.realmKey(prefix)
+	realmLen := len(b.realm)
+
+	return b.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = realmPrefix
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Seek(realmSeekKey); it.ValidForPrefix(realmPrefix); it.Next() {
+			item := it.Item()
+
+			proceed := true
+			if err := item.Value(func(value []byte) error {
+				proceed = cb(item.Key()[realmLen:], value)
+				return nil
+			}); err != nil {
+				return err
+			}
+			if !proceed {
+				return nil
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,61 @@
+package database
+
+import (
+	"bytes"
+
+	"github.com/iotaledger/hive.go/kvstore"
+)
+
+// boltStore adds the Viewer interface on top of a plain kvstore.KVStore by falling
+// back to a regular copying Get/Iterate. bbolt's own reads are only valid for the
+// lifetime of their transaction, and hive.go's bolt wrapper already closes that
+// transaction before returning, so there is no borrowed slice left to hand out by
+// the time Viewer would be able to call back into it.
+type boltStore struct {
+	kvstore.KVStore
+}
+
+// NewBoltKVStore wraps store as a kvstore.KVStore that also satisfies Viewer,
+// falling back to a copy on every read.
+func NewBoltKVStore(store kvstore.KVStore) kvstore.KVStore {
+	return &boltStore{KVStore: store}
+}
+
+// WithRealm and WithExtendedRealm are overridden so that scoping a boltStore to a
+// key prefix (as every real subsystem does) still returns something that satisfies
+// Viewer, instead of falling through to the embedded, unwrapped kvstore.KVStore.
+func (b *boltStore) WithRealm(realm kvstore.Realm) kvstore.KVStore {
+	return &boltStore{KVStore: b.KVStore.WithRealm(realm)}
+}
+
+func (b *boltStore) WithExtendedRealm(realm kvstore.Realm) kvstore.KVStore {
+	return &boltStore{KVStore: b.KVStore.WithExtendedRealm(realm)}
+}
+
+func (b *boltStore) View(key []byte, cb func([]byte) error) error {
+	value, err := b.KVStore.Get(key)
+	if err != nil {
+		return err
+	}
+	return cb(value)
+}
+
+func (b *boltStore) IterateView(prefix []byte, cb func(key []byte, value []byte) bool) error {
+	return b.KVStore.Iterate(prefix, func(key kvstore.Key, value kvstore.Value) bool {
+		return cb(key, value)
+	})
+}
+
+// IterateViewFrom has no real seek to fall back on here, since bbolt's cursor is
+// reached only through hive.go's own Iterate(prefix, ...). It walks the full prefix
+// like IterateView and skips everything before seekKey, which is correct but not
+// any cheaper than IterateView - callers on a backend without a true Viewer should
+// not expect the bound to save work.
+func (b *boltStore) IterateViewFrom(seekKey []byte, prefix []byte, cb func(key []byte, value []byte) bool) error {
+	return b.KVStore.Iterate(prefix, func(key kvstore.Key, value kvstore.Value) bool {
+		if bytes.Compare(key, seekKey) < 0 {
+			return true
+		}
+		return cb(key, value)
+	})
+}
@@ -0,0 +1,79 @@
+package database
+
+import (
+	"bytes"
+
+	"github.com/cockroachdb/pebble"
+
+	"github.com/iotaledger/hive.go/kvstore"
+	hivepebble "github.com/iotaledger/hive.go/kvstore/pebble"
+)
+
+// pebbleStore wraps the hive.go pebble kvstore with the zero-copy Viewer fast path,
+// reaching into the native *pebble.DB so reads and range scans can hand out the
+// bytes backing pebble's internal block cache directly.
+type pebbleStore struct {
+	kvstore.KVStore
+	db    *pebble.DB
+	realm kvstore.Realm
+}
+
+// NewPebbleKVStore wraps db as a kvstore.KVStore that also satisfies Viewer.
+func NewPebbleKVStore(db *pebble.DB) kvstore.KVStore {
+	return &pebbleStore{KVStore: hivepebble.New(db), db: db}
+}
+
+// WithRealm and WithExtendedRealm must be overridden here: every real subsystem
+// (storage managers, utxo.Manager, ...) scopes its working store by calling
+// WithRealm on the root store. If we let that fall through to the embedded,
+// unwrapped hive.go store, the result would stop satisfying Viewer and the fast
+// path this type exists for would never engage.
+func (p *pebbleStore) WithRealm(realm kvstore.Realm) kvstore.KVStore {
+	return &pebbleStore{KVStore: p.KVStore.WithRealm(realm), db: p.db, realm: realm}
+}
+
+func (p *pebbleStore) WithExtendedRealm(realm kvstore.Realm) kvstore.KVStore {
+	return p.WithRealm(append(append(kvstore.Realm{}, p.realm...), realm...))
+}
+
+func (p *pebbleStore) Realm() kvstore.Realm {
+	return p.realm
+}
+
+func (p *pebbleStore) realmKey(key []byte) []byte {
+	return append(append([]byte{}, p.realm...), key...)
+}
+
+func (p *pebbleStore) View(key []byte, cb func([]byte) error) error {
+	value, closer, err := p.db.Get(p.realmKey(key))
+	if err != nil {
+		if err == pebble.ErrNotFound {
+			return kvstore.ErrKeyNotFound
+		}
+		return err
+	}
+	defer closer.Close()
+
+	return cb(value)
+}
+
+func (p *pebbleStore) IterateView(prefix []byte, cb func(key []byte, value []byte) bool) error {
+	return p.IterateViewFrom(prefix, prefix, cb)
+}
+
+func (p *pebbleStore) IterateViewFrom(seekKey []byte, prefix []byte, cb func(key []byte, value []byte) bool) error {
+	realmSeekKey := p.realmKey(seekKey)
+	realmPrefix := p.realmKey(prefix)
+	realmLen := len(p.realm)
+
+	iter := p.db.NewIter(&pebble.IterOptions{})
+	defer iter.Close()
+
+	for iter.SeekGE(realmSeekKey); iter.Valid() && bytes.HasPrefix(iter.Key(), realmPrefix); iter.Next() {
+		if !cb(iter.Key()[realmLen:], iter.Value()) {
+			break
+		}
+	}
+
+	return iter.Error()
+}
@@ -1,19 +1,26 @@
 package utxo
 
 import (
+	"encoding/binary"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/iotaledger/hive.go/kvstore"
 	"github.com/iotaledger/hive.go/marshalutil"
 	iotago "github.com/iotaledger/iota.go/v2"
+
+	"github.com/gohornet/hornet/pkg/model/milestone"
 )
 
 const (
-	// A prefix which denotes a spent treasury output.
-	TreasuryOutputSpentPrefix = 1
-	// A prefix which denotes an unspent treasury output.
-	TreasuryOutputUnspentPrefix = 0
+	// UTXOStoreKeyPrefixTreasuryOutputSpentIndex prefixes the small spent-index marker
+	// record used to prefix-scan spent treasury outputs without reading every
+	// canonical record's value.
+	UTXOStoreKeyPrefixTreasuryOutputSpentIndex = UTXOStoreKeyPrefixTreasuryOutput + 1
+	// UTXOStoreKeyPrefixTreasuryOutputHistory prefixes the append-only treasury-history
+	// audit log, keyed by <milestoneIndex><MilestoneID>.
+	UTXOStoreKeyPrefixTreasuryOutputHistory = UTXOStoreKeyPrefixTreasuryOutput + 2
 )
 
 var (
@@ -22,26 +29,45 @@ var (
 )
 
 // TreasuryOutput represents the output of a treasury transaction.
+//
+// Its canonical record lives under a key derived purely from MilestoneID, so
+// flipping Spent is a single Set on the same key instead of a delete+set across two
+// keys that differ only in an encoded spent bit. A companion, key-only marker under
+// UTXOStoreKeyPrefixTreasuryOutputSpentIndex is kept in sync so spent outputs can
+// still be range-scanned without reading every canonical value.
 type TreasuryOutput struct {
 	// The ID of the milestone which generated this output.
 	MilestoneID iotago.MilestoneID
 	// The amount residing on this output.
 	Amount uint64
-	// Whether this output was already spent
+	// Whether this output was already spent.
 	Spent bool
+	// The index of the milestone that spent this output. Zero if Spent is false.
+	SpentAtMilestoneIndex milestone.Index
+	// The time at which this output was spent. The zero Time if Spent is false.
+	SpentAtTimestamp time.Time
 }
 
 func (t *TreasuryOutput) kvStorableKey() (key []byte) {
-	return marshalutil.New(34).
+	return marshalutil.New(1 + iotago.MilestoneIDLength).
 		WriteByte(UTXOStoreKeyPrefixTreasuryOutput).
-		WriteBool(t.Spent).
+		WriteBytes(t.MilestoneID[:]).
+		Bytes()
+}
+
+func (t *TreasuryOutput) spentIndexKey() (key []byte) {
+	return marshalutil.New(1 + iotago.MilestoneIDLength).
+		WriteByte(UTXOStoreKeyPrefixTreasuryOutputSpentIndex).
 		WriteBytes(t.MilestoneID[:]).
 		Bytes()
 }
 
 func (t *TreasuryOutput) kvStorableValue() (value []byte) {
-	return marshalutil.New(8).
+	return marshalutil.New(8 + 1 + 4 + 8).
 		WriteUint64(t.Amount).
+		WriteBool(t.Spent).
+		WriteUint32(uint32(t.SpentAtMilestoneIndex)).
+		WriteInt64(t.SpentAtTimestamp.Unix()).
 		Bytes()
 }
 
@@ -52,11 +78,6 @@ func (t *TreasuryOutput) kvStorableLoad(_ *Manager, key []byte, value []byte) er
 		return err
 	}
 
-	spent, err := keyExt.ReadBool()
-	if err != nil {
-		return err
-	}
-
 	milestoneID, err := keyExt.ReadBytes(iotago.MilestoneIDLength)
 	if err != nil {
 		return err
@@ -69,77 +90,120 @@ func (t *TreasuryOutput) kvStorableLoad(_ *Manager, key []byte, value []byte) er
 		return err
 	}
 
-	t.Spent = spent
+	t.Spent, err = val.ReadBool()
+	if err != nil {
+		return err
+	}
+
+	spentAtMilestoneIndex, err := val.ReadUint32()
+	if err != nil {
+		return err
+	}
+	t.SpentAtMilestoneIndex = milestone.Index(spentAtMilestoneIndex)
+
+	spentAtUnix, err := val.ReadInt64()
+	if err != nil {
+		return err
+	}
+	t.SpentAtTimestamp = time.Unix(spentAtUnix, 0)
 
 	return nil
 }
 
-// stores the given treasury output.
-func storeTreasuryOutput(output *TreasuryOutput, mutations kvstore.BatchedMutations) error {
-	return mutations.Set(output.kvStorableKey(), output.kvStorableValue())
+// stores the given treasury output, recording its creation at createdAtMilestoneIndex
+// in the treasury-history audit log.
+func storeTreasuryOutput(output *TreasuryOutput, createdAtMilestoneIndex milestone.Index, mutations kvstore.BatchedMutations) error {
+	if err := mutations.Set(output.kvStorableKey(), output.kvStorableValue()); err != nil {
+		return err
+	}
+	return appendTreasuryHistory(createdAtMilestoneIndex, output, TreasuryHistoryEventCreated, time.Now(), mutations)
 }
 
 // deletes the given treasury output.
 func deleteTreasuryOutput(output *TreasuryOutput, mutations kvstore.BatchedMutations) error {
-	return mutations.Delete(output.kvStorableKey())
+	if err := mutations.Delete(output.kvStorableKey()); err != nil {
+		return err
+	}
+	return mutations.Delete(output.spentIndexKey())
 }
 
-// marks the given treasury output as spent.
-func markTreasuryOutputAsSpent(output *TreasuryOutput, mutations kvstore.BatchedMutations) error {
+// marks the given treasury output as spent. Unlike the legacy layout, this is a
+// single Set on the unchanged canonical key, plus an additional marker write for
+// the spent-index so ForEachSpentTreasuryOutput can keep range-scanning spent
+// outputs directly.
+func markTreasuryOutputAsSpent(output *TreasuryOutput, spentAtMilestoneIndex milestone.Index, spentAtTimestamp time.Time, mutations kvstore.BatchedMutations) error {
 	outputCopy := *output
-	outputCopy.Spent = false
-	if err := mutations.Delete(outputCopy.kvStorableKey()); err != nil {
+	outputCopy.Spent = true
+	outputCopy.SpentAtMilestoneIndex = spentAtMilestoneIndex
+	outputCopy.SpentAtTimestamp = spentAtTimestamp
+
+	if err := mutations.Set(outputCopy.kvStorableKey(), outputCopy.kvStorableValue()); err != nil {
 		return err
 	}
-	outputCopy.Spent = true
-	return mutations.Set(outputCopy.kvStorableKey(), outputCopy.kvStorableValue())
+
+	if err := mutations.Set(outputCopy.spentIndexKey(), []byte{}); err != nil {
+		return err
+	}
+
+	return appendTreasuryHistory(spentAtMilestoneIndex, &outputCopy, TreasuryHistoryEventSpent, spentAtTimestamp, mutations)
 }
 
-// marks the given treasury output as unspent.
+// marks the given treasury output as unspent, e.g. when a milestone is rolled back.
 func markTreasuryOutputAsUnspent(output *TreasuryOutput, mutations kvstore.BatchedMutations) error {
 	outputCopy := *output
-	outputCopy.Spent = true
-	if err := mutations.Delete(outputCopy.kvStorableKey()); err != nil {
+	outputCopy.Spent = false
+	outputCopy.SpentAtMilestoneIndex = 0
+	outputCopy.SpentAtTimestamp = time.Time{}
+
+	if err := mutations.Set(outputCopy.kvStorableKey(), outputCopy.kvStorableValue()); err != nil {
 		return err
 	}
-	outputCopy.Spent = false
-	return mutations.Set(outputCopy.kvStorableKey(), outputCopy.kvStorableValue())
+
+	return mutations.Delete(outputCopy.spentIndexKey())
 }
 
-func (u *Manager) readSpentTreasuryOutputWithoutLocking(msHash []byte) (*TreasuryOutput, error) {
-	key := append([]byte{UTXOStoreKeyPrefixTreasuryOutput, TreasuryOutputSpentPrefix}, msHash...)
+func (u *Manager) readTreasuryOutputByMilestoneID(msID []byte) (*TreasuryOutput, error) {
+	to := &TreasuryOutput{}
+	copy(to.MilestoneID[:], msID)
+
+	key := to.kvStorableKey()
 	val, err := u.utxoStorage.Get(key)
 	if err != nil {
 		return nil, err
 	}
-	to := &TreasuryOutput{}
-	if err := to.kvStorableLoad(nil, key, val); err != nil {
+	if err := to.kvStorableLoad(u, key, val); err != nil {
 		return nil, err
 	}
 	return to, nil
 }
 
+// readSpentTreasuryOutputWithoutLocking reads the canonical treasury output created
+// by msHash. Since a spent-flag flip no longer changes the record's key, this reads
+// the same record readUnspentTreasuryOutputWithoutLocking would; callers are
+// expected to check TreasuryOutput.Spent themselves.
+func (u *Manager) readSpentTreasuryOutputWithoutLocking(msHash []byte) (*TreasuryOutput, error) {
+	return u.readTreasuryOutputByMilestoneID(msHash)
+}
+
 func (u *Manager) readUnspentTreasuryOutputWithoutLocking(msHash []byte) (*TreasuryOutput, error) {
-	key := append([]byte{UTXOStoreKeyPrefixTreasuryOutput, TreasuryOutputUnspentPrefix}, msHash...)
-	val, err := u.utxoStorage.Get(key)
-	if err != nil {
-		return nil, err
-	}
-	to := &TreasuryOutput{}
-	if err := to.kvStorableLoad(nil, key, val); err != nil {
-		return nil, err
-	}
-	return to, nil
+	return u.readTreasuryOutputByMilestoneID(msHash)
 }
 
-// AddTreasuryOutput adds the given treasury output to the database.
-func (u *Manager) AddTreasuryOutput(to *TreasuryOutput) error {
-	return u.utxoStorage.Set(to.kvStorableKey(), to.kvStorableValue())
+// AddTreasuryOutput adds the given treasury output to the database, recording its
+// creation at createdAtMilestoneIndex in the treasury-history audit log.
+func (u *Manager) AddTreasuryOutput(to *TreasuryOutput, createdAtMilestoneIndex milestone.Index) error {
+	if err := u.utxoStorage.Set(to.kvStorableKey(), to.kvStorableValue()); err != nil {
+		return err
+	}
+	return appendTreasuryHistory(createdAtMilestoneIndex, to, TreasuryHistoryEventCreated, time.Now(), u.utxoStorage)
 }
 
 // DeleteTreasuryOutput deletes the given treasury output from the database.
 func (u *Manager) DeleteTreasuryOutput(to *TreasuryOutput) error {
-	return u.utxoStorage.Delete(to.kvStorableKey())
+	if err := u.utxoStorage.Delete(to.kvStorableKey()); err != nil {
+		return err
+	}
+	return u.utxoStorage.Delete(to.spentIndexKey())
 }
 
 // Returns the unspent treasury output.
@@ -147,13 +211,19 @@ func (u *Manager) UnspentTreasuryOutput() (*TreasuryOutput, error) {
 	var i int
 	var innerErr error
 	var unspentTreasuryOutput *TreasuryOutput
-	if err := u.utxoStorage.Iterate([]byte{UTXOStoreKeyPrefixTreasuryOutput, TreasuryOutputUnspentPrefix}, func(key kvstore.Key, value kvstore.Value) bool {
-		i++
-		unspentTreasuryOutput = &TreasuryOutput{}
-		if err := unspentTreasuryOutput.kvStorableLoad(u, key, value); err != nil {
+	if err := iterateViewable(u.utxoStorage, []byte{UTXOStoreKeyPrefixTreasuryOutput}, func(key, value []byte) bool {
+		output := &TreasuryOutput{}
+		if err := output.kvStorableLoad(u, key, value); err != nil {
 			innerErr = err
 			return false
 		}
+
+		if output.Spent {
+			return true
+		}
+
+		i++
+		unspentTreasuryOutput = output
 		return true
 	}); err != nil {
 		return nil, err
@@ -186,7 +256,7 @@ func (u *Manager) ForEachTreasuryOutput(consumer TreasuryOutputConsumer, options
 
 	var innerErr error
 	var i int
-	if err := u.utxoStorage.Iterate([]byte{UTXOStoreKeyPrefixTreasuryOutput}, func(key kvstore.Key, value kvstore.Value) bool {
+	if err := iterateViewable(u.utxoStorage, []byte{UTXOStoreKeyPrefixTreasuryOutput}, func(key, value []byte) bool {
 
 		if (opt.maxResultCount > 0) && (i >= opt.maxResultCount) {
 			return false
@@ -208,6 +278,9 @@ func (u *Manager) ForEachTreasuryOutput(consumer TreasuryOutputConsumer, options
 	return innerErr
 }
 
+// ForEachSpentTreasuryOutput walks the spent-index prefix rather than filtering the
+// canonical records by value, so a scan over spent outputs only pays for a value
+// read on the outputs it actually returns.
 func (u *Manager) ForEachSpentTreasuryOutput(consumer TreasuryOutputConsumer, options ...UTXOIterateOption) error {
 
 	opt := iterateOptions(options)
@@ -219,21 +292,222 @@ func (u *Manager) ForEachSpentTreasuryOutput(consumer TreasuryOutputConsumer, op
 
 	var innerErr error
 	var i int
-	if err := u.utxoStorage.Iterate([]byte{UTXOStoreKeyPrefixTreasuryOutput, TreasuryOutputSpentPrefix}, func(key kvstore.Key, value kvstore.Value) bool {
+	if err := iterateViewable(u.utxoStorage, []byte{UTXOStoreKeyPrefixTreasuryOutputSpentIndex}, func(key, _ []byte) bool {
 
 		if (opt.maxResultCount > 0) && (i >= opt.maxResultCount) {
 			return false
 		}
 
+		output, err := u.readTreasuryOutputByMilestoneID(key[1:])
+		if err != nil {
+			innerErr = err
+			return false
+		}
+
 		i++
 
-		output := &TreasuryOutput{}
-		if err := output.kvStorableLoad(u, key, value); err != nil {
+		return consumer(output)
+	}); err != nil {
+		return err
+	}
+
+	return innerErr
+}
+
+// TreasuryHistoryEventType denotes the kind of mutation recorded for a treasury
+// output in the treasury-history audit log.
+type TreasuryHistoryEventType byte
+
+const (
+	// TreasuryHistoryEventCreated marks the creation of a new treasury output.
+	TreasuryHistoryEventCreated TreasuryHistoryEventType = iota
+	// TreasuryHistoryEventSpent marks a treasury output being spent by a milestone.
+	TreasuryHistoryEventSpent
+	// TreasuryHistoryEventMigratedFromLegacy marks an output rewritten by the v3
+	// storage migration from the pre-v3 key layout; its spend history predating the
+	// migration could not be reconstructed.
+	TreasuryHistoryEventMigratedFromLegacy
+)
+
+// TreasuryHistoryEvent is a single, immutable entry in the treasury-history audit
+// log, giving operators and explorer integrators a trail of treasury output
+// creations, spends and legacy-layout migrations without having to replay
+// milestones. Marking an output unspent again or deleting it is not recorded.
+type TreasuryHistoryEvent struct {
+	// MilestoneIndex is the milestone during which this event was recorded. For
+	// TreasuryHistoryEventMigratedFromLegacy entries it is the output's
+	// SpentAtMilestoneIndex, which is zero for outputs read from the pre-v3 layout.
+	MilestoneIndex milestone.Index `json:"milestoneIndex"`
+	// MilestoneID is the ID of the treasury output this event concerns.
+	MilestoneID iotago.MilestoneID `json:"milestoneId"`
+	// Amount is the amount residing on the treasury output at the time of the event.
+	Amount uint64 `json:"amount"`
+	// EventType denotes the kind of mutation this event records.
+	EventType TreasuryHistoryEventType `json:"eventType"`
+	// Timestamp is the spend time passed to markTreasuryOutputAsSpent for spent
+	// events, and the wall clock time at which the event was recorded otherwise.
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// treasuryHistoryIndexBytes encodes msIndex big-endian, so history keys sort by
+// milestone index.
+func treasuryHistoryIndexBytes(msIndex milestone.Index) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, uint32(msIndex))
+	return b
+}
+
+func (e *TreasuryHistoryEvent) kvStorableKey() (key []byte) {
+	return marshalutil.New(1 + 4 + iotago.MilestoneIDLength).
+		WriteByte(UTXOStoreKeyPrefixTreasuryOutputHistory).
+		WriteBytes(treasuryHistoryIndexBytes(e.MilestoneIndex)).
+		WriteBytes(e.MilestoneID[:]).
+		Bytes()
+}
+
+func (e *TreasuryHistoryEvent) kvStorableValue() (value []byte) {
+	return marshalutil.New(8 + 1 + 8).
+		WriteUint64(e.Amount).
+		WriteByte(byte(e.EventType)).
+		WriteInt64(e.Timestamp.Unix()).
+		Bytes()
+}
+
+func treasuryHistoryEventFromKV(key []byte, value []byte) (*TreasuryHistoryEvent, error) {
+	keyExt := marshalutil.New(key)
+	// skip prefix
+	if _, err := keyExt.ReadByte(); err != nil {
+		return nil, err
+	}
+
+	msIndexBytes, err := keyExt.ReadBytes(4)
+	if err != nil {
+		return nil, err
+	}
+	msIndex := binary.BigEndian.Uint32(msIndexBytes)
+
+	milestoneID, err := keyExt.ReadBytes(iotago.MilestoneIDLength)
+	if err != nil {
+		return nil, err
+	}
+
+	valExt := marshalutil.New(value)
+	amount, err := valExt.ReadUint64()
+	if err != nil {
+		return nil, err
+	}
+
+	eventType, err := valExt.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	unixTimestamp, err := valExt.ReadInt64()
+	if err != nil {
+		return nil, err
+	}
+
+	event := &TreasuryHistoryEvent{
+		MilestoneIndex: milestone.Index(msIndex),
+		Amount:         amount,
+		EventType:      TreasuryHistoryEventType(eventType),
+		Timestamp:      time.Unix(unixTimestamp, 0),
+	}
+	copy(event.MilestoneID[:], milestoneID)
+
+	return event, nil
+}
+
+// kvSetter is satisfied by both kvstore.KVStore and kvstore.BatchedMutations, letting
+// appendTreasuryHistory append to the audit log either as part of a larger batch or
+// as a standalone write.
+type kvSetter interface {
+	Set(key kvstore.Key, value kvstore.Value) error
+}
+
+// appendTreasuryHistory appends an immutable record to the treasury-history audit log.
+func appendTreasuryHistory(msIndex milestone.Index, output *TreasuryOutput, eventType TreasuryHistoryEventType, at time.Time, mutations kvSetter) error {
+	event := &TreasuryHistoryEvent{
+		MilestoneIndex: msIndex,
+		MilestoneID:    output.MilestoneID,
+		Amount:         output.Amount,
+		EventType:      eventType,
+		Timestamp:      at,
+	}
+	return mutations.Set(event.kvStorableKey(), event.kvStorableValue())
+}
+
+// MigrateTreasuryOutputLayout writes output using the current key layout and appends
+// a TreasuryHistoryEventMigratedFromLegacy entry. It is used by the v3 storage
+// migration to rewrite outputs stored under the pre-v3 key layout.
+func MigrateTreasuryOutputLayout(output *TreasuryOutput, mutations kvstore.BatchedMutations) error {
+	if err := mutations.Set(output.kvStorableKey(), output.kvStorableValue()); err != nil {
+		return err
+	}
+
+	if output.Spent {
+		if err := mutations.Set(output.spentIndexKey(), []byte{}); err != nil {
+			return err
+		}
+	}
+
+	return appendTreasuryHistory(output.SpentAtMilestoneIndex, output, TreasuryHistoryEventMigratedFromLegacy, time.Now(), mutations)
+}
+
+type TreasuryHistoryConsumer func(event *TreasuryHistoryEvent) bool
+
+// treasuryHistorySeekKey builds the key the history log's first entry at or after
+// msIndex would have, for seeking straight to it instead of scanning from the start
+// of the log. The key layout sorts by milestone index right after the prefix byte,
+// so this is always <= the real key of any entry at that index, regardless of its
+// MilestoneID.
+func treasuryHistorySeekKey(msIndex milestone.Index) []byte {
+	return marshalutil.New(1 + 4).
+		WriteByte(UTXOStoreKeyPrefixTreasuryOutputHistory).
+		WriteBytes(treasuryHistoryIndexBytes(msIndex)).
+		Bytes()
+}
+
+// ForEachTreasuryHistory walks the append-only treasury-history audit log for
+// milestone indices within [from, to], giving operators and explorer integrators a
+// reliable audit trail across coordinator migrations without having to replay
+// milestones. The underlying scan is seeked directly to from instead of walking
+// and discarding every earlier entry, since the log's key layout sorts by
+// milestone index.
+func (u *Manager) ForEachTreasuryHistory(from, to milestone.Index, consumer TreasuryHistoryConsumer, options ...UTXOIterateOption) error {
+
+	opt := iterateOptions(options)
+
+	if opt.readLockLedger {
+		u.ReadLockLedger()
+		defer u.ReadUnlockLedger()
+	}
+
+	prefix := []byte{UTXOStoreKeyPrefixTreasuryOutputHistory}
+
+	var innerErr error
+	var i int
+	if err := iterateViewableFrom(u.utxoStorage, treasuryHistorySeekKey(from), prefix, func(key, value []byte) bool {
+
+		if (opt.maxResultCount > 0) && (i >= opt.maxResultCount) {
+			return false
+		}
+
+		event, err := treasuryHistoryEventFromKV(key, value)
+		if err != nil {
 			innerErr = err
 			return false
 		}
 
-		return consumer(output)
+		// the scan is already positioned at from, so anything beyond to means
+		// every following entry is too, given the key layout's sort order.
+		if event.MilestoneIndex > to {
+			return false
+		}
+
+		i++
+
+		return consumer(event)
 	}); err != nil {
 		return err
 	}
@@ -0,0 +1,38 @@
+package utxo
+
+import (
+	"bytes"
+
+	"github.com/iotaledger/hive.go/kvstore"
+
+	"github.com/gohornet/hornet/pkg/database"
+)
+
+// iterateViewable walks every key/value pair under prefix, preferring the
+// zero-copy database.Viewer fast path when the underlying store supports it, so a
+// full treasury scan does not force a copy per entry. Falls back to a regular
+// kvstore.KVStore.Iterate for backends that don't implement Viewer.
+//
+// consumer must not retain key or value beyond the call, as both may be borrowed
+// from the backend's internal buffers.
+func iterateViewable(store kvstore.KVStore, prefix []byte, consumer func(key, value []byte) bool) error {
+	return iterateViewableFrom(store, prefix, prefix, consumer)
+}
+
+// iterateViewableFrom behaves like iterateViewable, except it seeks directly to
+// seekKey instead of starting at prefix, bounding the scan over a key layout that
+// sorts by some field embedded right after the prefix. Falls back to a plain
+// prefix scan, filtering out everything before seekKey, for backends that don't
+// implement database.Viewer.
+func iterateViewableFrom(store kvstore.KVStore, seekKey []byte, prefix []byte, consumer func(key, value []byte) bool) error {
+	if viewer, ok := store.(database.Viewer); ok {
+		return viewer.IterateViewFrom(seekKey, prefix, consumer)
+	}
+
+	return store.Iterate(prefix, func(key kvstore.Key, value kvstore.Value) bool {
+		if bytes.Compare(key, seekKey) < 0 {
+			return true
+		}
+		return consumer(key, value)
+	})
+}